@@ -19,7 +19,9 @@ package rustprebuilts
 import (
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/google/blueprint/proptools"
 
@@ -28,14 +30,24 @@ import (
 	"android/soong/rust/config"
 )
 
-// This module is used to generate the rust host stdlib prebuilts
-// When RUST_PREBUILTS_VERSION is set, the library will generated
-// from the given Rust version.
+// These modules are used to generate the rust host and device stdlib
+// prebuilts, and the prebuilt proc-macro, sanitizer runtime and test binary
+// modules. When RUST_PREBUILTS_VERSION is set, the library will be
+// generated from the given Rust version.
 func init() {
 	android.RegisterModuleType("rust_stdlib_prebuilt_host",
 		rustHostPrebuiltSysrootLibraryFactory)
+	android.RegisterModuleType("rust_stdlib_prebuilt",
+		rustDevicePrebuiltSysrootLibraryFactory)
 	android.RegisterModuleType("rust_stdlib_prebuilt_filegroup_host",
 		rustToolchainFilegroupFactory)
+	android.RegisterModuleType("rust_proc_macro_prebuilt_host",
+		rustHostPrebuiltProcMacroFactory)
+	android.RegisterModuleType("rust_sanitizer_runtime_prebuilt",
+		rustSanitizerRuntimePrebuiltFactory)
+	android.RegisterModuleType("rust_test_prebuilt_host",
+		rustHostPrebuiltTestFactory)
+	android.PostDepsMutators(registerRustPrebuiltApexVariantMutator)
 }
 
 func getRustPrebuiltVersion(ctx android.LoadHookContext) string {
@@ -48,8 +60,11 @@ func getRustLibDir(ctx android.LoadHookContext) string {
 }
 
 // getPrebuilt returns the module relative Rust library path and the suffix hash.
-func getPrebuilt(ctx android.LoadHookContext, dir, lib, extension string) (string, string) {
-	globPath := path.Join(ctx.ModuleDir(), dir, lib) + "-*" + extension
+// suffix is the fixed trailing portion of the filename after the hash, e.g. a
+// plain file extension like ".rlib" or a longer tail like "_rt.asan.a" for
+// sanitizer runtimes; either way it's trimmed verbatim to isolate the hash.
+func getPrebuilt(ctx android.LoadHookContext, dir, lib, suffix string) (string, string) {
+	globPath := path.Join(ctx.ModuleDir(), dir, lib) + "-*" + suffix
 	libMatches := ctx.Glob(globPath, nil)
 
 	if len(libMatches) != 1 {
@@ -57,13 +72,21 @@ func getPrebuilt(ctx android.LoadHookContext, dir, lib, extension string) (strin
 		return "", ""
 	}
 
-	// Collect the suffix by trimming the extension from the Base, then removing the library name and hyphen.
-	suffix := strings.TrimSuffix(libMatches[0].Base(), extension)[len(lib)+1:]
+	// Collect the hash by trimming the suffix from the Base, then removing the library name and hyphen.
+	hash := strings.TrimSuffix(libMatches[0].Base(), suffix)[len(lib)+1:]
 
 	// Get the relative path from the match by trimming out the module directory.
 	relPath := strings.TrimPrefix(libMatches[0].String(), ctx.ModuleDir()+"/")
 
-	return relPath, suffix
+	return relPath, hash
+}
+
+// getPrebuiltSanitizerRuntime returns the module relative path to the
+// prebuilt Rust sanitizer runtime archive (librustc-<hash>_rt.<sanitizer>.a)
+// under dir, reusing getPrebuilt's hash-suffix resolution.
+func getPrebuiltSanitizerRuntime(ctx android.LoadHookContext, dir, sanitizer string) string {
+	lib, _ := getPrebuilt(ctx, dir, "librustc", "_rt."+sanitizer+".a")
+	return lib
 }
 
 type targetProps struct {
@@ -86,6 +109,13 @@ type props struct {
 		Linux_musl_x86_64  targetProps
 		Linux_musl_x86     targetProps
 		Darwin_x86_64      targetProps
+		Darwin_arm64       targetProps
+		Linux_glibc_arm64  targetProps
+		Linux_musl_arm64   targetProps
+		Android_arm        targetProps
+		Android_arm64      targetProps
+		Android_x86        targetProps
+		Android_x86_64     targetProps
 	}
 }
 
@@ -113,6 +143,79 @@ func (target *targetProps) addPrebuiltToTarget(ctx android.LoadHookContext, libN
 	}
 }
 
+// hostTarget is one (OS, arch) combination these host prebuilts ship for,
+// naming the prebuilt tree's platform subdirectory, the rustc target triple,
+// and the bp Target.<field> stanza that carries them.
+type hostTarget struct {
+	os          android.OsType
+	arch        android.ArchType
+	targetField string
+	platformDir string
+	archTriple  string
+}
+
+// hostTargets is the single source of truth for the platformDir/archTriple
+// mapping shared by constructLibProps, rustSanitizerRuntimePrebuiltFactory
+// and rustToolchainFilegroupFactory, so no factory needs its own
+// arch-branching logic. A new host target is added here, and each of those
+// factories' Target structs must also carry a field named by its targetField.
+var hostTargets = []hostTarget{
+	{android.Linux, android.X86_64, "Linux_glibc_x86_64", "linux-x86", "x86_64-unknown-linux-gnu"},
+	{android.Linux, android.X86, "Linux_glibc_x86", "linux-x86", "i686-unknown-linux-gnu"},
+	{android.Linux, android.Arm64, "Linux_glibc_arm64", "linux-arm64", "aarch64-unknown-linux-gnu"},
+	{android.LinuxMusl, android.X86_64, "Linux_musl_x86_64", "linux-musl-x86", "x86_64-unknown-linux-musl"},
+	{android.LinuxMusl, android.X86, "Linux_musl_x86", "linux-musl-x86", "i686-unknown-linux-musl"},
+	{android.LinuxMusl, android.Arm64, "Linux_musl_arm64", "linux-musl-arm64", "aarch64-unknown-linux-musl"},
+	{android.Darwin, android.X86_64, "Darwin_x86_64", "darwin-x86", "x86_64-apple-darwin"},
+	{android.Darwin, android.Arm64, "Darwin_arm64", "darwin-arm64", "aarch64-apple-darwin"},
+}
+
+// buildHostTargets returns the hostTargets for the current BuildOS: on an
+// arm64 host that's the single arm64 entry, on an x86 host it's every
+// non-arm64 entry for that OS (glibc/musl Linux each ship both x86_64 and
+// x86 prebuilts from one x86 build machine).
+func buildHostTargets(ctx android.LoadHookContext) []hostTarget {
+	buildOS := ctx.Config().BuildOS
+	buildArch := ctx.Config().BuildArch()
+	var targets []hostTarget
+	for _, t := range hostTargets {
+		if t.os != buildOS {
+			continue
+		}
+		if buildArch == android.Arm64 {
+			if t.arch == android.Arm64 {
+				targets = append(targets, t)
+			}
+		} else if t.arch != android.Arm64 {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// currentHostTarget returns the single hostTarget exactly matching the
+// current BuildOS/BuildArch, for callers like rustToolchainFilegroupFactory
+// that need one target per arch rather than buildHostTargets' "arm64 vs
+// everything else" grouping.
+func currentHostTarget(ctx android.LoadHookContext) (hostTarget, bool) {
+	buildOS := ctx.Config().BuildOS
+	buildArch := ctx.Config().BuildArch()
+	for _, t := range hostTargets {
+		if t.os == buildOS && t.arch == buildArch {
+			return t, true
+		}
+	}
+	return hostTarget{}, false
+}
+
+// targetStructField returns the addressable field named name on target
+// (which must be a pointer to a struct, e.g. &p.Target), letting callers
+// address a hostTarget's Target.<field> stanza by the name recorded in
+// hostTarget instead of a per-arch switch statement.
+func targetStructField(target interface{}, name string) reflect.Value {
+	return reflect.ValueOf(target).Elem().FieldByName(name)
+}
+
 func constructLibProps(rlib, solib bool) func(ctx android.LoadHookContext) {
 	return func(ctx android.LoadHookContext) {
 		rustDir := getRustLibDir(ctx)
@@ -122,31 +225,324 @@ func constructLibProps(rlib, solib bool) func(ctx android.LoadHookContext) {
 		p := props{}
 		p.Enabled = proptools.BoolPtr(false)
 
-		if ctx.Config().BuildOS == android.Linux {
-			p.Target.Linux_glibc_x86_64.addPrebuiltToTarget(ctx, name, rustDir, "linux-x86", "x86_64-unknown-linux-gnu", rlib, solib)
-			p.Target.Linux_glibc_x86.addPrebuiltToTarget(ctx, name, rustDir, "linux-x86", "i686-unknown-linux-gnu", rlib, solib)
-		} else if ctx.Config().BuildOS == android.LinuxMusl {
-			p.Target.Linux_musl_x86_64.addPrebuiltToTarget(ctx, name, rustDir, "linux-musl-x86", "x86_64-unknown-linux-musl", rlib, solib)
-			p.Target.Linux_musl_x86.addPrebuiltToTarget(ctx, name, rustDir, "linux-musl-x86", "i686-unknown-linux-musl", rlib, solib)
-		} else if ctx.Config().BuildOS == android.Darwin {
-			p.Target.Darwin_x86_64.addPrebuiltToTarget(ctx, name, rustDir, "darwin-x86", "x86_64-apple-darwin", rlib, solib)
+		for _, t := range buildHostTargets(ctx) {
+			target := targetStructField(&p.Target, t.targetField).Addr().Interface().(*targetProps)
+			target.addPrebuiltToTarget(ctx, name, rustDir, t.platformDir, t.archTriple, rlib, solib)
 		}
 
 		ctx.AppendProperties(&p)
 	}
 }
 
+func constructDeviceLibProps(rlib, solib bool) func(ctx android.LoadHookContext) {
+	return func(ctx android.LoadHookContext) {
+		rustDir := getRustLibDir(ctx)
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName())
+		name = strings.Replace(name, ".rust_sysroot", "", -1)
+		platform := config.HostPrebuiltTag(ctx.Config())
+
+		p := props{}
+		p.Enabled = proptools.BoolPtr(false)
+
+		for _, prop := range ctx.Module().GetProperties() {
+			if apexProps, ok := prop.(*apexPrebuiltProperties); ok {
+				if len(apexProps.Apex_variants) > 0 {
+					recordApexOverrides(ctx.ModuleName(), apexProps.Apex_variants)
+				}
+			}
+		}
+
+		p.Target.Android_arm.addPrebuiltToTarget(ctx, name, rustDir, platform, "armv7-linux-android", rlib, solib)
+		p.Target.Android_arm64.addPrebuiltToTarget(ctx, name, rustDir, platform, "aarch64-linux-android", rlib, solib)
+		p.Target.Android_x86.addPrebuiltToTarget(ctx, name, rustDir, platform, "i686-linux-android", rlib, solib)
+		p.Target.Android_x86_64.addPrebuiltToTarget(ctx, name, rustDir, platform, "x86_64-linux-android", rlib, solib)
+
+		ctx.AppendProperties(&p)
+	}
+}
+
 func rustHostPrebuiltSysrootLibraryFactory() android.Module {
 	module, _ := rust.NewPrebuiltLibrary(android.HostSupported)
 	android.AddLoadHook(module, constructLibProps( /*rlib=*/ true /*solib=*/, true))
 	return module.Init()
 }
 
+// perApexTargetProps overrides the platform variant's Suffix/Dylib.Srcs for
+// a single named APEX, e.g. to point at a stripped-symbols copy of the
+// prebuilt dylib.
+type perApexTargetProps struct {
+	// Name of the APEX this override applies to.
+	Apex_name string
+	Suffix    *string
+	Dylib     struct {
+		Srcs []string
+	}
+}
+
+type apexPrebuiltProperties struct {
+	// Apex_variants lists per-APEX Suffix/Dylib.Srcs overrides, applied to
+	// the android_* variant CreateApexVariations creates for that APEX.
+	Apex_variants []perApexTargetProps
+}
+
+// apexVariationsLock guards perApexOverrides, which is written from
+// constructDeviceLibProps load hooks and read back by
+// rustPrebuiltApexVariantMutator once CreateApexVariations has split the
+// module into one variant per APEX.
+var apexVariationsLock sync.Mutex
+var perApexOverrides = make(map[string]map[string]perApexTargetProps)
+
+func recordApexOverrides(moduleName string, variants []perApexTargetProps) {
+	apexVariationsLock.Lock()
+	defer apexVariationsLock.Unlock()
+
+	byApex := make(map[string]perApexTargetProps, len(variants))
+	for _, v := range variants {
+		byApex[v.Apex_name] = v
+	}
+	perApexOverrides[moduleName] = byApex
+}
+
+// setApexVariantOverride overwrites the already arch-resolved Suffix and
+// Dylib.Srcs fields on module's own properties struct with override's. By
+// the time PostDepsMutators runs, the arch mutator has long since flattened
+// this module's Target.android_<arch>.* stanzas onto those top-level fields
+// and won't look at Target.* again, so appending a fresh props{} here (as
+// earlier versions of this mutator did) is a no-op; the fields have to be
+// overwritten in place on whichever properties struct already carries them.
+func setApexVariantOverride(module android.Module, override perApexTargetProps) bool {
+	for _, prop := range module.GetProperties() {
+		v := reflect.ValueOf(prop)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		s := v.Elem()
+		suffixField := s.FieldByName("Suffix")
+		if !suffixField.IsValid() || suffixField.Type() != reflect.TypeOf(override.Suffix) || !suffixField.CanSet() {
+			continue
+		}
+		dylibField := s.FieldByName("Dylib")
+		if !dylibField.IsValid() || dylibField.Kind() != reflect.Struct {
+			continue
+		}
+		srcsField := dylibField.FieldByName("Srcs")
+		if !srcsField.IsValid() || srcsField.Type() != reflect.TypeOf(override.Dylib.Srcs) || !srcsField.CanSet() {
+			continue
+		}
+
+		suffixField.Set(reflect.ValueOf(override.Suffix))
+		srcsField.Set(reflect.ValueOf(override.Dylib.Srcs))
+		return true
+	}
+	return false
+}
+
+// rustPrebuiltApexVariantMutator applies the overrides recorded by
+// constructDeviceLibProps to the apex variant CreateApexVariations produced
+// for that APEX, so each APEX can ship its own stripped Suffix/Dylib.Srcs
+// instead of the platform variant's.
+func rustPrebuiltApexVariantMutator(ctx android.TopDownMutatorContext) {
+	apexVariationsLock.Lock()
+	overrides, ok := perApexOverrides[ctx.ModuleName()]
+	apexVariationsLock.Unlock()
+	if !ok {
+		return
+	}
+
+	if _, ok := ctx.Module().(android.ApexModule); !ok {
+		return
+	}
+
+	apexInfo, ok := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	if !ok {
+		return
+	}
+	override, ok := overrides[apexInfo.ApexVariationName]
+	if !ok {
+		return
+	}
+
+	if !setApexVariantOverride(ctx.Module(), override) {
+		ctx.ModuleErrorf("rust_stdlib_prebuilt: could not find a Suffix/Dylib.Srcs field to override for apex %q", apexInfo.ApexVariationName)
+	}
+}
+
+func registerRustPrebuiltApexVariantMutator(ctx android.RegisterMutatorsContext) {
+	ctx.TopDown("rust_stdlib_prebuilt_apex_variants", rustPrebuiltApexVariantMutator)
+}
+
+func rustDevicePrebuiltSysrootLibraryFactory() android.Module {
+	module, _ := rust.NewPrebuiltLibrary(android.DeviceSupported)
+	module.AddProperties(&apexPrebuiltProperties{})
+	android.AddLoadHook(module, constructDeviceLibProps( /*rlib=*/ true /*solib=*/, true))
+	return module.Init()
+}
+
+// rust_proc_macro_prebuilt_host imports a prebuilt proc-macro dylib
+// (lib<name>-<hash>.so / .dylib) so it can be consumed by the Rust
+// compiler via --extern proc_macro.
+func rustHostPrebuiltProcMacroFactory() android.Module {
+	module, _ := rust.NewPrebuiltProcMacro(android.HostSupported)
+	android.AddLoadHook(module, constructLibProps( /*rlib=*/ false /*solib=*/, true))
+	return module.Init()
+}
+
+type testBinTargetProps struct {
+	Srcs    []string
+	Enabled *bool
+}
+
+type testPrebuiltProperties struct {
+	// Test suite to register this binary under for Tradefed/atest discovery.
+	Test_suite *string
+}
+
+// setTestSuites sets the Test_suites []string field that
+// rust.NewPrebuiltTest's own properties already expose, instead of
+// re-declaring a second Test_suites field here: two property structs on the
+// same module declaring the same bp field name fail to register.
+func setTestSuites(module android.Module, suites []string) bool {
+	for _, prop := range module.GetProperties() {
+		v := reflect.ValueOf(prop)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		f := v.Elem().FieldByName("Test_suites")
+		if !f.IsValid() || f.Type() != reflect.TypeOf(suites) || !f.CanSet() {
+			continue
+		}
+		f.Set(reflect.ValueOf(suites))
+		return true
+	}
+	return false
+}
+
+// rust_test_prebuilt_host imports a prebuilt Rust test binary (e.g. rustc's
+// own compiletest, a cargo test binary, or libtest's JSON-emitting runner)
+// shipped under <host-prebuilt>/<RUST_PREBUILTS_VERSION>/bin/.
+func rustHostPrebuiltTestFactory() android.Module {
+	module, _ := rust.NewPrebuiltTest(android.HostSupported)
+	module.AddProperties(&testPrebuiltProperties{})
+	android.AddLoadHook(module, func(ctx android.LoadHookContext) {
+		var testProps *testPrebuiltProperties
+		for _, prop := range ctx.Module().GetProperties() {
+			if tp, ok := prop.(*testPrebuiltProperties); ok {
+				testProps = tp
+			}
+		}
+
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName())
+		binDir := path.Join(getRustPrebuiltVersion(ctx), "bin")
+
+		p := struct {
+			Enabled *bool
+			Target  struct {
+				Linux_glibc_x86_64 testBinTargetProps
+				Linux_musl_x86_64  testBinTargetProps
+				Darwin_x86_64      testBinTargetProps
+			}
+		}{}
+		p.Enabled = proptools.BoolPtr(false)
+		if testProps.Test_suite != nil {
+			if !setTestSuites(ctx.Module(), []string{*testProps.Test_suite}) {
+				ctx.ModuleErrorf("rust_test_prebuilt_host: could not find a Test_suites field to set")
+			}
+		}
+
+		if ctx.Config().BuildOS == android.Linux {
+			p.Target.Linux_glibc_x86_64.Srcs = []string{path.Join("linux-x86", binDir, name)}
+			p.Target.Linux_glibc_x86_64.Enabled = proptools.BoolPtr(true)
+		} else if ctx.Config().BuildOS == android.LinuxMusl {
+			p.Target.Linux_musl_x86_64.Srcs = []string{path.Join("linux-musl-x86", binDir, name)}
+			p.Target.Linux_musl_x86_64.Enabled = proptools.BoolPtr(true)
+		} else if ctx.Config().BuildOS == android.Darwin {
+			p.Target.Darwin_x86_64.Srcs = []string{path.Join("darwin-x86", binDir, name)}
+			p.Target.Darwin_x86_64.Enabled = proptools.BoolPtr(true)
+		}
+
+		ctx.AppendProperties(&p)
+	})
+	return module.Init()
+}
+
+type sanitizerRuntimeProperties struct {
+	// Which sanitizer runtime to import: "asan", "ubsan", "tsan", "msan" or "hwasan".
+	Sanitizer *string
+}
+
+// rust_sanitizer_runtime_prebuilt imports a prebuilt Rust sanitizer runtime
+// archive (librustc-<hash>_rt.<sanitizer>.a) for the build host's targets
+// (as chosen by buildHostTargets) and every Android target; host targets of
+// other OSes or arches are left without srcs. cc and rust modules can then
+// depend on a named target
+// instead of globbing the toolchain tree themselves.
+func rustSanitizerRuntimePrebuiltFactory() android.Module {
+	module := android.FileGroupFactory()
+	module.AddProperties(&sanitizerRuntimeProperties{})
+	android.AddLoadHook(module, func(ctx android.LoadHookContext) {
+		var sanitizerProps *sanitizerRuntimeProperties
+		for _, p := range ctx.Module().GetProperties() {
+			if sp, ok := p.(*sanitizerRuntimeProperties); ok {
+				sanitizerProps = sp
+			}
+		}
+
+		if sanitizerProps.Sanitizer == nil {
+			ctx.ModuleErrorf("rust_sanitizer_runtime_prebuilt requires a sanitizer property")
+			return
+		}
+		sanitizer := *sanitizerProps.Sanitizer
+		rustDir := getRustLibDir(ctx)
+
+		p := struct {
+			Target struct {
+				Linux_glibc_x86_64 archTargetProps
+				Linux_glibc_x86    archTargetProps
+				Linux_glibc_arm64  archTargetProps
+				Linux_musl_x86_64  archTargetProps
+				Linux_musl_x86     archTargetProps
+				Linux_musl_arm64   archTargetProps
+				Darwin_x86_64      archTargetProps
+				Darwin_arm64       archTargetProps
+				Android_arm        archTargetProps
+				Android_arm64      archTargetProps
+				Android_x86        archTargetProps
+				Android_x86_64     archTargetProps
+			}
+		}{}
+
+		addRuntime := func(target *archTargetProps, platform, arch string) {
+			dir := path.Join(platform, rustDir, arch, "lib")
+			target.Srcs = []string{getPrebuiltSanitizerRuntime(ctx, dir, sanitizer)}
+		}
+
+		for _, t := range buildHostTargets(ctx) {
+			target := targetStructField(&p.Target, t.targetField).Addr().Interface().(*archTargetProps)
+			addRuntime(target, t.platformDir, t.archTriple)
+		}
+
+		androidPlatform := config.HostPrebuiltTag(ctx.Config())
+		addRuntime(&p.Target.Android_arm, androidPlatform, "armv7-linux-android")
+		addRuntime(&p.Target.Android_arm64, androidPlatform, "aarch64-linux-android")
+		addRuntime(&p.Target.Android_x86, androidPlatform, "i686-linux-android")
+		addRuntime(&p.Target.Android_x86_64, androidPlatform, "x86_64-linux-android")
+
+		ctx.AppendProperties(&p)
+	})
+	return module
+}
+
 type toolchainFilegroupProperties struct {
 	// path to toolchain files, relative to the top of the toolchain source
 	Toolchain_srcs []string
 }
 
+// archTargetProps holds the arch-qualified srcs for a single host target
+// variant of a rust_stdlib_prebuilt_filegroup_host.
+type archTargetProps struct {
+	Srcs []string
+}
+
 func rustToolchainFilegroupFactory() android.Module {
 	module := android.FileGroupFactory()
 	module.AddProperties(&toolchainFilegroupProperties{})
@@ -159,29 +555,34 @@ func rustToolchainFilegroupFactory() android.Module {
 			}
 		}
 
-		var archTriple string
-		if ctx.Config().BuildOS == android.Linux {
-			archTriple = "x86_64-unknown-linux-gnu"
-			archTriple = "i686-unknown-linux-gnu"
-		} else if ctx.Config().BuildOS == android.LinuxMusl {
-			archTriple = "x86_64-unknown-linux-musl"
-			archTriple = "i686-unknown-linux-musl"
-		} else if ctx.Config().BuildOS == android.Darwin {
-			archTriple = "x86_64-apple-darwin"
+		p := struct {
+			Target struct {
+				Linux_glibc_x86_64 archTargetProps
+				Linux_glibc_x86    archTargetProps
+				Linux_glibc_arm64  archTargetProps
+				Linux_musl_x86_64  archTargetProps
+				Linux_musl_x86     archTargetProps
+				Linux_musl_arm64   archTargetProps
+				Darwin_x86_64      archTargetProps
+				Darwin_arm64       archTargetProps
+			}
+		}{}
+
+		t, ok := currentHostTarget(ctx)
+		if !ok {
+			ctx.ModuleErrorf("unsupported host os/arch for rust_stdlib_prebuilt_filegroup_host")
+			return
 		}
+		target := targetStructField(&p.Target, t.targetField).Addr().Interface().(*archTargetProps)
 
-		prefix := filepath.Join(config.HostPrebuiltTag(ctx.Config()), rust.GetRustPrebuiltVersion(ctx), "lib", "rustlib", archTriple)
+		prefix := filepath.Join(config.HostPrebuiltTag(ctx.Config()), rust.GetRustPrebuiltVersion(ctx), "lib", "rustlib", t.archTriple)
 		srcs := make([]string, 0, len(toolchainProps.Toolchain_srcs))
 		for _, s := range toolchainProps.Toolchain_srcs {
 			srcs = append(srcs, path.Join(prefix, s))
 		}
+		target.Srcs = srcs
 
-		props := struct {
-			Srcs []string
-		}{
-			Srcs: srcs,
-		}
-		ctx.AppendProperties(&props)
+		ctx.AppendProperties(&p)
 	})
 	return module
 }